@@ -24,17 +24,21 @@ package main
 
 import (
 	"bufio"
+	"encoding/binary"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"math"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -47,19 +51,28 @@ import (
 )
 
 var (
-	delay     time.Duration
-	batchmode = flag.Bool("b", false, "batch mode")
-	version   = flag.Bool("v", false, "show version")
-	help      = flag.Bool("h", false, "print this help")
-	debug     abool.AtomicBool
-	blue      = color.New(color.FgBlue).SprintFunc()
-	cyan      = color.New(color.FgCyan).SprintFunc()
-	green     = color.New(color.FgGreen).SprintfFunc()
-	magenta   = color.New(color.FgMagenta).SprintfFunc()
-	red       = color.New(color.FgRed).SprintfFunc()
-	rootdev   = getRootdev()
-	GitCommit string
-	BuildDate string
+	delay      time.Duration
+	batchmode  = flag.Bool("b", false, "batch mode")
+	version    = flag.Bool("v", false, "show version")
+	help       = flag.Bool("h", false, "print this help")
+	cgroupFlag = flag.Bool("cgroup", false, "report cgroup limits instead of host-wide stats")
+	listen     = flag.String("listen", "", "address to expose Prometheus metrics on, e.g. :9100")
+	format     = flag.String("format", "text", "batch mode output format: text or json")
+	debug      abool.AtomicBool
+	blue       = color.New(color.FgBlue).SprintFunc()
+	cyan       = color.New(color.FgCyan).SprintFunc()
+	green      = color.New(color.FgGreen).SprintfFunc()
+	magenta    = color.New(color.FgMagenta).SprintfFunc()
+	red        = color.New(color.FgRed).SprintfFunc()
+	rootdev    = getRootdev()
+	GitCommit  string
+	BuildDate  string
+
+	cgMu        sync.Mutex
+	cgroupVer   string
+	cgroupMode  bool
+	cgPrevUsage uint64
+	cgPrevTime  time.Time
 )
 
 func usage() {
@@ -80,6 +93,8 @@ func specialKeys() {
 	fmt.Fprintln(os.Stderr, "  m : run stress-ng matrix on one threads for 10 sec")
 	fmt.Fprintln(os.Stderr, "  M : run stress-ng matrix on all threads for 10 sec")
 	fmt.Fprintln(os.Stderr, "  r : reset min/max counters")
+	fmt.Fprintln(os.Stderr, "  p : toggle process panel / cycle sort order (CPU, RSS, IO)")
+	fmt.Fprintln(os.Stderr, "  g : toggle bars/sparklines for CPU freq, load, and temps")
 	fmt.Fprintln(os.Stderr, "  h : help")
 	fmt.Fprintln(os.Stderr, "  q : quit")
 }
@@ -96,8 +111,21 @@ func main() {
 		flag.Usage()
 		os.Exit(0)
 	}
+	cgroupVer = cgroupVersion()
+	cgroupMode = cgroupVer != "" && (*cgroupFlag || inContainer())
+	if *listen != "" {
+		go serveMetrics(*listen)
+	}
 	if *batchmode {
-		fmt.Print(str())
+		if *format == "json" {
+			out, err := renderJSON(takeSnapshot())
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(out)
+		} else {
+			fmt.Print(str())
+		}
 		os.Exit(0)
 	}
 	if err := tb.Init(); err != nil {
@@ -180,6 +208,10 @@ func keyHandler(keyChan chan<- rune) {
 			go exec.Command("/usr/bin/stress-ng", "--matrix", "0", "--timeout", "10s").Run()
 		case 'r':
 			resetFreqBuffer()
+		case 'p':
+			toggleProcPanel()
+		case 'g':
+			showSparklines.Toggle()
 		default:
 			keyChan <- k
 		}
@@ -197,11 +229,23 @@ func timed(f func() string) string {
 }
 
 func str() string {
-	s := timed(cpuinfo)
+	var s string
+	if cgroupMode {
+		s += cgroupStatus() + "\n"
+	}
+	s += timed(cpuinfo)
 	s += "Memory [MiB]\n" + timed(meminfo) + "\n"
 	s += "Load average\n" + timed(loadAvg) + "\n"
 	s += "Root disk\n" + timed(df) + "\n\n"
-	s += "Sensors\n" + timed(sensors)
+	s += "Network\n" + timed(netinfo) + "\n"
+	procMu.Lock()
+	procsOn := showProcsOn
+	procMu.Unlock()
+	if procsOn {
+		s += "Processes\n" + timed(procinfo) + "\n"
+	}
+	s += "Sensors\n" + timed(sensors) + "\n"
+	s += "Battery\n" + timed(battery)
 	return s
 }
 
@@ -225,19 +269,13 @@ func read(path string) string {
 	return strings.TrimSpace(string(buf[:n]))
 }
 
-func sensors() string {
-	var str string
+func sensorsSnapshot() (temps []Temp, fans []Fan, err error) {
 	const dir = "/sys/class/hwmon"
 	dirs, err := ioutil.ReadDir(dir)
 	if err != nil {
-		if _, ok := err.(*os.PathError); ok {
-			return ""
-		}
-		log.Fatal(err)
+		return nil, nil, err
 	}
 
-	var temps []Temp
-	var fans []Fan
 	for _, d := range dirs {
 		subdir := filepath.Join(dir, d.Name())
 		if d.Mode()&os.ModeSymlink == 0 {
@@ -261,13 +299,73 @@ func sensors() string {
 			}
 		}
 	}
+	return temps, fans, nil
+}
+
+var (
+	tempMu      sync.Mutex
+	tempHistory = make(map[string]*ring)
+)
+
+// recordTempHistory appends the current sensor readings to tempHistory. It is
+// called from sensors(), not from sensorsSnapshot(): sensorsSnapshot() is
+// also called from the /metrics scrape path in takeSnapshot(), and appending
+// there would let scrape cadence leak into the sparkline sample rate instead
+// of the TUI's own refresh tick. recordLoadHistory and recordFreqHistory
+// follow the same rule for their renderers.
+func recordTempHistory(temps []Temp) {
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	for _, t := range temps {
+		v, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			continue
+		}
+		key := t.module + "/" + t.label
+		if tempHistory[key] == nil {
+			tempHistory[key] = newRing()
+		}
+		tempHistory[key].add(v / 1000)
+	}
+}
+
+func sensors() string {
+	temps, fans, err := sensorsSnapshot()
+	if err != nil {
+		if _, ok := err.(*os.PathError); ok {
+			return ""
+		}
+		log.Fatal(err)
+	}
+	recordTempHistory(temps)
+
+	useSpark := showSparklines.Get()
+	width := sparkWidth()
+	tempStr := func(t Temp) string {
+		s := t.String()
+		if !useSpark {
+			return s
+		}
+		tempMu.Lock()
+		var vals []float64
+		if hist := tempHistory[t.module+"/"+t.label]; hist != nil {
+			vals = hist.values()
+		}
+		tempMu.Unlock()
+		if vals == nil {
+			return s
+		}
+		return s + "  " + magenta("%s", sparkline(vals, width))
+	}
+
+	var str string
 	n := calcMinInt(len(temps), len(fans))
 	var i int
 	for i = 0; i < n; i++ {
-		str += fmt.Sprintf(" %s     %s\n", temps[i], fans[i])
+		str += fmt.Sprintf(" %s     %s\n", tempStr(temps[i]), fans[i])
 	}
 	for i := i; i < len(temps); i++ {
-		str += fmt.Sprintf(" %s\n", temps[i])
+		str += fmt.Sprintf(" %s\n", tempStr(temps[i]))
 	}
 	for i := i; i < len(fans); i++ {
 		str += fmt.Sprintf(" %s\n", fans[i])
@@ -346,7 +444,135 @@ func (f Fan) String() string {
 	return fmt.Sprintf("%-10s   %s rpm", f.label, magenta("%4s", f.value))
 }
 
-func loadAvg() string {
+var (
+	battMu      sync.Mutex
+	minCapacity = make(map[string]int)
+	maxCapacity = make(map[string]int)
+)
+
+func battery() string {
+	const dir = "/sys/class/power_supply"
+	dirs, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if _, ok := err.(*os.PathError); ok {
+			return ""
+		}
+		return err.Error()
+	}
+
+	var str string
+	for _, d := range dirs {
+		subdir := filepath.Join(dir, d.Name())
+		switch read(filepath.Join(subdir, "type")) {
+		case "Battery":
+			str += batteryLine(subdir, d.Name())
+		case "Mains":
+			str += mainsLine(subdir, d.Name())
+		}
+	}
+	return str
+}
+
+func readInt64(path string) (int64, bool) {
+	v, err := strconv.ParseInt(read(path), 10, 64)
+	return v, err == nil
+}
+
+func batteryLine(dir, name string) string {
+	capacity, err := strconv.Atoi(read(filepath.Join(dir, "capacity")))
+	if err != nil {
+		return ""
+	}
+	status := read(filepath.Join(dir, "status"))
+
+	battMu.Lock()
+	minCapacity[name] = calcMinCapacity(minCapacity[name], capacity)
+	maxCapacity[name] = calcMaxCapacity(maxCapacity[name], capacity)
+	min, max := minCapacity[name], maxCapacity[name]
+	battMu.Unlock()
+
+	energyNow, okEN := readInt64(filepath.Join(dir, "energy_now"))
+	energyFull, okEF := readInt64(filepath.Join(dir, "energy_full"))
+	usingEnergy := okEN && okEF
+	if !usingEnergy {
+		energyNow, okEN = readInt64(filepath.Join(dir, "charge_now"))
+		energyFull, okEF = readInt64(filepath.Join(dir, "charge_full"))
+	}
+	voltageNow, _ := readInt64(filepath.Join(dir, "voltage_now"))
+
+	eta := "-"
+	if okEN && okEF {
+		// energy_* is in µWh, matching power_now's µW. charge_* is in µAh
+		// instead, so it needs current_now's µA to keep the units consistent.
+		var rate int64
+		var okRate bool
+		if usingEnergy {
+			rate, okRate = readInt64(filepath.Join(dir, "power_now"))
+		} else {
+			rate, okRate = readInt64(filepath.Join(dir, "current_now"))
+		}
+		if okRate && rate > 0 {
+			switch status {
+			case "Discharging":
+				eta = (time.Duration(float64(energyNow)/float64(rate)*3600) * time.Second).Truncate(time.Minute).String()
+			case "Charging":
+				if energyFull > energyNow {
+					eta = (time.Duration(float64(energyFull-energyNow)/float64(rate)*3600) * time.Second).Truncate(time.Minute).String()
+				}
+			}
+		}
+	}
+
+	return fmt.Sprintf(" %-10s %s%%  %-11s  %s V  min:%d%% max:%d%%  eta:%s\n",
+		name, cyan(fmt.Sprintf("%3d", capacity)), status,
+		magenta("%.2f", float64(voltageNow)/1e6), min, max, eta)
+}
+
+func mainsLine(dir, name string) string {
+	state := "offline"
+	if read(filepath.Join(dir, "online")) == "1" {
+		state = "online"
+	}
+	return fmt.Sprintf(" %-10s %s\n", name, cyan(state))
+}
+
+func calcMinCapacity(a, b int) int {
+	if a == 0 {
+		return b
+	}
+	if b == 0 {
+		return a
+	}
+	if b < a {
+		return b
+	}
+	return a
+}
+
+func calcMaxCapacity(a, b int) int {
+	if a == 0 {
+		return b
+	}
+	if b == 0 {
+		return a
+	}
+	if b > a {
+		return b
+	}
+	return a
+}
+
+type loadStats struct {
+	load1, load5, load15 float64
+	runnable, lastPID    string
+}
+
+var (
+	loadMu      sync.Mutex
+	loadHistory = newRing()
+)
+
+func loadSnapshot() (loadStats, error) {
 	// 1    2    3    4 5   6
 	// 0.00 0.12 0.09 1/371 4461
 	// 123) 1, 5, and 15 minutes.
@@ -355,36 +581,187 @@ func loadAvg() string {
 	//   6) The fifth field is the PID of the process that was most recently created on the system.
 	bytes, err := ioutil.ReadFile("/proc/loadavg")
 	if err != nil {
-		return ""
+		return loadStats{}, err
 	}
 	f := strings.Fields(string(bytes))
-	return fmt.Sprintf(" %s %s %s %s %s\n", cyan(f[0]), cyan(f[1]), cyan(f[2]), f[3], f[4])
+	if len(f) < 5 {
+		return loadStats{}, fmt.Errorf("unexpected /proc/loadavg format: %q", bytes)
+	}
+	var ls loadStats
+	ls.load1, err = strconv.ParseFloat(f[0], 64)
+	if err != nil {
+		return loadStats{}, err
+	}
+	ls.load5, err = strconv.ParseFloat(f[1], 64)
+	if err != nil {
+		return loadStats{}, err
+	}
+	ls.load15, err = strconv.ParseFloat(f[2], 64)
+	if err != nil {
+		return loadStats{}, err
+	}
+	ls.runnable, ls.lastPID = f[3], f[4]
+
+	return ls, nil
+}
+
+// recordLoadHistory appends the current 1-minute load average to loadHistory.
+// See recordTempHistory for why this lives in the renderer (loadAvg) and not
+// in loadSnapshot().
+func recordLoadHistory(load1 float64) {
+	loadMu.Lock()
+	loadHistory.add(load1)
+	loadMu.Unlock()
+}
+
+func loadAvg() string {
+	ls, err := loadSnapshot()
+	if err != nil {
+		return ""
+	}
+	recordLoadHistory(ls.load1)
+	load1 := cyan(fmt.Sprintf("%.2f", ls.load1))
+	if showSparklines.Get() {
+		loadMu.Lock()
+		vals := loadHistory.values()
+		loadMu.Unlock()
+		load1 = fmt.Sprintf("%s %s", load1, magenta("%s", sparkline(vals, sparkWidth())))
+	}
+	return fmt.Sprintf(" %s %s %s %s %s\n",
+		load1, cyan(fmt.Sprintf("%.2f", ls.load5)), cyan(fmt.Sprintf("%.2f", ls.load15)),
+		ls.runnable, ls.lastPID)
+}
+
+const ringSize = 120
+
+// ring is a fixed-size circular buffer of recent samples, oldest first.
+type ring struct {
+	data []float64
+	pos  int
+	full bool
+}
+
+func newRing() *ring { return &ring{data: make([]float64, ringSize)} }
+
+func (r *ring) add(v float64) {
+	r.data[r.pos] = v
+	r.pos = (r.pos + 1) % ringSize
+	if r.pos == 0 {
+		r.full = true
+	}
+}
+
+func (r *ring) values() []float64 {
+	if !r.full {
+		return append([]float64(nil), r.data[:r.pos]...)
+	}
+	out := make([]float64, ringSize)
+	n := copy(out, r.data[r.pos:])
+	copy(out[n:], r.data[:r.pos])
+	return out
 }
 
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders the last width samples of values as a Unicode block
+// sparkline, scaled to the min/max observed in that window.
+func sparkline(values []float64, width int) string {
+	if len(values) == 0 {
+		return ""
+	}
+	if width > 0 && len(values) > width {
+		values = values[len(values)-width:]
+	}
+	min, max := values[0], values[0]
+	for _, v := range values {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	rng := max - min
+	var b strings.Builder
+	for _, v := range values {
+		idx := 0
+		if rng > 0 {
+			idx = int((v - min) / rng * float64(len(sparkBlocks)-1))
+		}
+		b.WriteRune(sparkBlocks[idx])
+	}
+	return b.String()
+}
+
+func sparkWidth() int {
+	w, _ := tb.Size()
+	if w <= 20 {
+		return 40
+	}
+	return w - 20
+}
+
+var showSparklines abool.AtomicBool
+
 var (
 	fileCpuinfo *os.File
 	mu          sync.Mutex
 	minFreq     = make(map[int]float64)
 	maxFreq     = make(map[int]float64)
+	freqHistory = make(map[int]*ring)
 )
 
 func resetFreqBuffer() {
 	mu.Lock()
-	defer mu.Unlock()
 	for i := range minFreq {
 		minFreq[i] = 0
 	}
 	for i := range maxFreq {
 		maxFreq[i] = 0
 	}
+	for i := range freqHistory {
+		freqHistory[i] = newRing()
+	}
+	mu.Unlock()
+
+	netMu.Lock()
+	for i := range minRate {
+		minRate[i] = 0
+	}
+	for i := range maxRate {
+		maxRate[i] = 0
+	}
+	netMu.Unlock()
+
+	loadMu.Lock()
+	loadHistory = newRing()
+	loadMu.Unlock()
+
+	tempMu.Lock()
+	for i := range tempHistory {
+		tempHistory[i] = newRing()
+	}
+	tempMu.Unlock()
+
+	battMu.Lock()
+	for i := range minCapacity {
+		minCapacity[i] = 0
+	}
+	for i := range maxCapacity {
+		maxCapacity[i] = 0
+	}
+	battMu.Unlock()
 }
 
-func cpuinfo() string {
+type CoreFreq struct {
+	ID  int     `json:"id"`
+	MHz float64 `json:"mhz"`
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+func cpuSnapshot() []CoreFreq {
 	var err error
-	const (
-		header = "Core          0         1         2         3         4  GHz    Min  Max"
-		footer = "              0         1         2         3         4  GHz    Min  Max"
-	)
 	if fileCpuinfo == nil {
 		fileCpuinfo, err = os.Open("/proc/cpuinfo")
 		if err != nil {
@@ -393,7 +770,6 @@ func cpuinfo() string {
 	}
 	unix.Seek(int(fileCpuinfo.Fd()), 0, 0)
 	scanner := bufio.NewScanner(fileCpuinfo)
-	str := fmt.Sprintln(header)
 
 	// processor	: 1
 	// cpu MHz		: 2103.707
@@ -401,7 +777,7 @@ func cpuinfo() string {
 	// physical id	: 0
 	// core id		: 1
 	// cpu cores	: 8
-	var cores = make(map[int]string)
+	var cores = make(map[int]float64)
 	var coreIdStr string
 	var mhzStr string
 	for scanner.Scan() {
@@ -435,15 +811,8 @@ func cpuinfo() string {
 			if err != nil {
 				log.Fatalf("parse error: %v", err)
 			}
-			x := int(math.Round(mhz / 100))
-			bar := green(strings.Repeat("#", x))
-			if x < 40 {
-				bar += strings.Repeat(" ", 39-x) + "|       "
-			} else {
-				bar += strings.Repeat(" ", 47-x)
-			}
 
-			cores[core_id] = fmt.Sprintf("%s MHz |%s", red("%4.0f", mhz), bar)
+			cores[core_id] = mhz
 			mu.Lock()
 			minFreq[core_id] = calcMinFreq(minFreq[core_id], mhz)
 			maxFreq[core_id] = calcMaxFreq(maxFreq[core_id], mhz)
@@ -457,21 +826,77 @@ func cpuinfo() string {
 	}
 
 	mu.Lock()
+	defer mu.Unlock()
+	result := make([]CoreFreq, len(cores))
 	for i := 0; i < len(cores); i++ {
-		str += fmt.Sprintf(" %2d: %s  %s %s\n", i, cores[i], green("%4.0f", minFreq[i]), red("%4.0f", maxFreq[i]))
+		result[i] = CoreFreq{ID: i, MHz: cores[i], Min: minFreq[i], Max: maxFreq[i]}
+	}
+	return result
+}
+
+// recordFreqHistory appends the current per-core frequencies to freqHistory.
+// See recordTempHistory for why this lives in the renderer (cpuinfo) and not
+// in cpuSnapshot().
+func recordFreqHistory(cores []CoreFreq) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, c := range cores {
+		if freqHistory[c.ID] == nil {
+			freqHistory[c.ID] = newRing()
+		}
+		freqHistory[c.ID].add(c.MHz)
+	}
+}
+
+func cpuinfo() string {
+	const (
+		header = "Core          0         1         2         3         4  GHz    Min  Max"
+		footer = "              0         1         2         3         4  GHz    Min  Max"
+	)
+	useSpark := showSparklines.Get()
+	width := sparkWidth()
+	cores := cpuSnapshot()
+	recordFreqHistory(cores)
+	str := fmt.Sprintln(header)
+	for _, c := range cores {
+		var bar string
+		if useSpark {
+			mu.Lock()
+			var vals []float64
+			if hist := freqHistory[c.ID]; hist != nil {
+				vals = hist.values()
+			}
+			mu.Unlock()
+			bar = magenta("%s", sparkline(vals, width))
+		} else {
+			x := int(math.Round(c.MHz / 100))
+			bar = green(strings.Repeat("#", x))
+			if x < 40 {
+				bar += strings.Repeat(" ", 39-x) + "|       "
+			} else {
+				bar += strings.Repeat(" ", 47-x)
+			}
+		}
+		str += fmt.Sprintf(" %2d: %s MHz |%s  %s %s\n", c.ID, red("%4.0f", c.MHz), bar, green("%4.0f", c.Min), red("%4.0f", c.Max))
 	}
-	mu.Unlock()
 	str += fmt.Sprintln(footer)
 	return str
 }
 
 var reMemory = regexp.MustCompile(":?\\s+")
 
-func meminfo() string {
+type MemStats struct {
+	TotalKiB     uint64 `json:"total_kib"`
+	FreeKiB      uint64 `json:"free_kib"`
+	AvailableKiB uint64 `json:"available_kib"`
+	SwapUsedKiB  uint64 `json:"swap_used_kib"`
+}
+
+func memSnapshot() (MemStats, error) {
 	mem := make(map[string]int)
 	f, err := os.Open("/proc/meminfo")
 	if err != nil {
-		return err.Error()
+		return MemStats{}, err
 	}
 	defer f.Close()
 	scanner := bufio.NewScanner(f)
@@ -491,14 +916,37 @@ func meminfo() string {
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return err.Error()
+		return MemStats{}, err
 	}
 
+	return MemStats{
+		TotalKiB:     uint64(mem["MemTotal"]),
+		FreeKiB:      uint64(mem["MemFree"]),
+		AvailableKiB: uint64(mem["MemAvailable"]),
+		SwapUsedKiB:  uint64(mem["SwapTotal"] - mem["SwapFree"]),
+	}, nil
+}
+
+func meminfo() string {
+	if cgroupMode {
+		if current, limit, ok := cgroupMemStats(); ok {
+			return fmt.Sprintf(" total:%s used:%s free:%s (cgroup limit)\n",
+				cyan(fmt.Sprintf("%d", limit/1024/1024)),
+				cyan(fmt.Sprintf("%d", current/1024/1024)),
+				cyan(fmt.Sprintf("%d", (limit-current)/1024/1024)),
+			)
+		}
+	}
+
+	m, err := memSnapshot()
+	if err != nil {
+		return err.Error()
+	}
 	return fmt.Sprintf(" total:%s free:%s available:%s swap:%s\n",
-		cyan(fmt.Sprintf("%d", mem["MemTotal"]/1024)),
-		cyan(fmt.Sprintf("%d", mem["MemFree"]/1024)),
-		cyan(fmt.Sprintf("%d", mem["MemAvailable"]/1024)),
-		cyan(fmt.Sprintf("%d", (mem["SwapTotal"]-mem["SwapFree"])/1024)),
+		cyan(fmt.Sprintf("%d", m.TotalKiB/1024)),
+		cyan(fmt.Sprintf("%d", m.FreeKiB/1024)),
+		cyan(fmt.Sprintf("%d", m.AvailableKiB/1024)),
+		cyan(fmt.Sprintf("%d", m.SwapUsedKiB/1024)),
 	)
 }
 
@@ -524,22 +972,503 @@ func getRootdev() string {
 	return dev
 }
 
-func df() string {
+type DiskStats struct {
+	Device string `json:"device"`
+	Size   uint64 `json:"size_bytes"`
+	Used   uint64 `json:"used_bytes"`
+	Free   uint64 `json:"free_bytes"`
+}
+
+func diskSnapshot() DiskStats {
 	const dir = "/"
 	var stat unix.Statfs_t
 	unix.Statfs(dir, &stat)
 	size := stat.Blocks * uint64(stat.Bsize)
 	free := stat.Bfree * uint64(stat.Bsize)
-	used := size - free
+	return DiskStats{Device: rootdev, Size: size, Used: size - free, Free: free}
+}
 
+func df() string {
+	d := diskSnapshot()
 	return fmt.Sprintf(" %s size:%s  used:%s  free:%s",
-		rootdev,
-		humanBytes(size),
-		humanBytes(used),
-		humanBytes(free),
+		d.Device,
+		humanBytes(d.Size),
+		humanBytes(d.Used),
+		humanBytes(d.Free),
 	)
 }
 
+type netSample struct {
+	rxBytes, rxPackets uint64
+	txBytes, txPackets uint64
+	time               time.Time
+}
+
+var (
+	netMu   sync.Mutex
+	netPrev = make(map[string]netSample)
+	minRate = make(map[string]float64)
+	maxRate = make(map[string]float64)
+)
+
+func netinfo() string {
+	f, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return err.Error()
+	}
+	defer f.Close()
+
+	now := time.Now()
+	var str string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.Contains(line, ":") {
+			continue
+		}
+		fields := strings.Fields(strings.Replace(line, ":", " ", 1))
+		if len(fields) < 17 {
+			continue
+		}
+		iface := fields[0]
+		if iface == "lo" {
+			continue
+		}
+		rxBytes, _ := strconv.ParseUint(fields[1], 10, 64)
+		rxPackets, _ := strconv.ParseUint(fields[2], 10, 64)
+		txBytes, _ := strconv.ParseUint(fields[9], 10, 64)
+		txPackets, _ := strconv.ParseUint(fields[10], 10, 64)
+
+		netMu.Lock()
+		prev, ok := netPrev[iface]
+		netPrev[iface] = netSample{rxBytes, rxPackets, txBytes, txPackets, now}
+		netMu.Unlock()
+
+		var rxRate, txRate, rxPpsRate, txPpsRate float64
+		if ok {
+			elapsed := now.Sub(prev.time).Seconds()
+			if elapsed > 0 {
+				rxRate = float64(rxBytes-prev.rxBytes) / elapsed
+				txRate = float64(txBytes-prev.txBytes) / elapsed
+				rxPpsRate = float64(rxPackets-prev.rxPackets) / elapsed
+				txPpsRate = float64(txPackets-prev.txPackets) / elapsed
+			}
+		}
+
+		netMu.Lock()
+		minRate[iface] = calcMinRate(minRate[iface], rxRate+txRate)
+		maxRate[iface] = calcMaxRate(maxRate[iface], rxRate+txRate)
+		min, max := minRate[iface], maxRate[iface]
+		netMu.Unlock()
+
+		str += fmt.Sprintf(" %-8s rx:%s (%s pkts)  tx:%s (%s pkts)\n",
+			iface,
+			cyan(humanBytes(rxBytes)), cyan(fmt.Sprintf("%d", rxPackets)),
+			cyan(humanBytes(txBytes)), cyan(fmt.Sprintf("%d", txPackets)),
+		)
+		str += fmt.Sprintf("          rx:%s (%s pkts/s)  tx:%s (%s pkts/s)  min:%s max:%s\n",
+			humanRate(rxRate), magenta("%.0f", rxPpsRate),
+			humanRate(txRate), magenta("%.0f", txPpsRate),
+			humanRate(min), humanRate(max),
+		)
+	}
+	if err := scanner.Err(); err != nil {
+		return err.Error()
+	}
+	return str
+}
+
+func calcMinRate(a, b float64) float64 {
+	if a == 0 {
+		return b
+	}
+	if b == 0 {
+		return a
+	}
+	if b < a {
+		return b
+	}
+	return a
+}
+
+func calcMaxRate(a, b float64) float64 {
+	if a == 0 {
+		return b
+	}
+	if b == 0 {
+		return a
+	}
+	if b > a {
+		return b
+	}
+	return a
+}
+
+func humanRate(val float64) string {
+	units := []string{"B/s", "KB/s", "MB/s", "GB/s", "TB/s"}
+	i := 0
+	for val >= 1024 && i < len(units)-1 {
+		val /= 1024
+		i++
+	}
+	return magenta("%.1f %s", val, units[i])
+}
+
+const procTopN = 5
+
+// clockTicksPerSec is sysconf(_SC_CLK_TCK): the number of scheduler ticks
+// per second that /proc/[pid]/stat's utime/stime fields are counted in.
+// Go has no direct sysconf() call; the kernel hands the same value to every
+// process via the AT_CLKTCK auxiliary vector entry, so we read it from
+// /proc/self/auxv instead. 100 is the value on every common Linux target
+// and is used as a fallback if the auxv can't be read or parsed.
+var clockTicksPerSec = sysconfClockTicks()
+
+func sysconfClockTicks() float64 {
+	const (
+		atClkTck = 17
+		wordSize = 8 // auxv entries are native-width word pairs on amd64/arm64
+	)
+	data, err := ioutil.ReadFile("/proc/self/auxv")
+	if err != nil {
+		return 100
+	}
+	for i := 0; i+2*wordSize <= len(data); i += 2 * wordSize {
+		tag := binary.LittleEndian.Uint64(data[i : i+wordSize])
+		if tag == 0 {
+			break
+		}
+		if tag == atClkTck {
+			return float64(binary.LittleEndian.Uint64(data[i+wordSize : i+2*wordSize]))
+		}
+	}
+	return 100
+}
+
+type procSample struct {
+	cpuTicks uint64
+	ioBytes  uint64
+	time     time.Time
+}
+
+type procStat struct {
+	pid     int
+	comm    string
+	cpuPct  float64
+	rssMiB  float64
+	threads int
+	ioRate  float64
+}
+
+var (
+	procMu       sync.Mutex
+	procPrev     = make(map[int]procSample)
+	showProcsOn  bool
+	procSortMode int
+)
+
+func toggleProcPanel() {
+	procMu.Lock()
+	defer procMu.Unlock()
+	if !showProcsOn {
+		showProcsOn = true
+		procSortMode = 0
+		return
+	}
+	procSortMode++
+	if procSortMode > 2 {
+		procSortMode = 0
+		showProcsOn = false
+	}
+}
+
+func procinfo() string {
+	procMu.Lock()
+	sortMode := procSortMode
+	procMu.Unlock()
+
+	dirs, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return err.Error()
+	}
+
+	now := time.Now()
+	seen := make(map[int]bool)
+	var procs []procStat
+	for _, d := range dirs {
+		pid, err := strconv.Atoi(d.Name())
+		if err != nil {
+			continue
+		}
+		seen[pid] = true
+		if p, ok := readProc(pid, now); ok {
+			procs = append(procs, p)
+		}
+	}
+
+	procMu.Lock()
+	for pid := range procPrev {
+		if !seen[pid] {
+			delete(procPrev, pid)
+		}
+	}
+	procMu.Unlock()
+
+	sortLabel := "CPU"
+	switch sortMode {
+	case 1:
+		sortLabel = "RSS"
+		sort.Slice(procs, func(i, j int) bool { return procs[i].rssMiB > procs[j].rssMiB })
+	case 2:
+		sortLabel = "IO"
+		sort.Slice(procs, func(i, j int) bool { return procs[i].ioRate > procs[j].ioRate })
+	default:
+		sort.Slice(procs, func(i, j int) bool { return procs[i].cpuPct > procs[j].cpuPct })
+	}
+
+	str := fmt.Sprintf(" sort:%s\n", sortLabel)
+	n := calcMinInt(procTopN, len(procs))
+	for i := 0; i < n; i++ {
+		p := procs[i]
+		str += fmt.Sprintf(" %6d %-16s %s%%  %s MiB  %s  thr:%d\n",
+			p.pid, p.comm,
+			cyan(fmt.Sprintf("%5.1f", p.cpuPct)),
+			cyan(fmt.Sprintf("%7.1f", p.rssMiB)),
+			magenta("%s", humanRate(p.ioRate)),
+			p.threads,
+		)
+	}
+	return str
+}
+
+func readProc(pid int, now time.Time) (procStat, bool) {
+	raw, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return procStat{}, false
+	}
+	s := string(raw)
+	open := strings.IndexByte(s, '(')
+	end := strings.LastIndexByte(s, ')')
+	if open < 0 || end < 0 || end < open {
+		return procStat{}, false
+	}
+	comm := s[open+1 : end]
+	fields := strings.Fields(s[end+1:])
+	if len(fields) < 15 {
+		return procStat{}, false
+	}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	cpuTicks := utime + stime
+
+	status, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return procStat{}, false
+	}
+	defer status.Close()
+	var rssKB, threads int
+	var sawRSS bool
+	scanner := bufio.NewScanner(status)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "VmRSS:"):
+			fmt.Sscanf(line, "VmRSS: %d", &rssKB)
+			sawRSS = true
+		case strings.HasPrefix(line, "Threads:"):
+			fmt.Sscanf(line, "Threads: %d", &threads)
+		}
+	}
+	if !sawRSS {
+		// Kernel threads have no VmRSS line in status; fall back to statm's
+		// resident page count.
+		if raw, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/statm", pid)); err == nil {
+			fields := strings.Fields(string(raw))
+			if len(fields) >= 2 {
+				if pages, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+					rssKB = int(pages * int64(os.Getpagesize()) / 1024)
+				}
+			}
+		}
+	}
+
+	var ioBytes uint64
+	if raw, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/io", pid)); err == nil {
+		for _, line := range strings.Split(string(raw), "\n") {
+			if strings.HasPrefix(line, "read_bytes:") || strings.HasPrefix(line, "write_bytes:") {
+				if f := strings.Fields(line); len(f) == 2 {
+					v, _ := strconv.ParseUint(f[1], 10, 64)
+					ioBytes += v
+				}
+			}
+		}
+	}
+
+	procMu.Lock()
+	prev, ok := procPrev[pid]
+	procPrev[pid] = procSample{cpuTicks: cpuTicks, ioBytes: ioBytes, time: now}
+	procMu.Unlock()
+
+	var cpuPct, ioRate float64
+	if ok {
+		elapsed := now.Sub(prev.time).Seconds()
+		if elapsed > 0 {
+			if cpuTicks >= prev.cpuTicks {
+				cpuPct = float64(cpuTicks-prev.cpuTicks) / clockTicksPerSec / elapsed * 100
+			}
+			if ioBytes >= prev.ioBytes {
+				ioRate = float64(ioBytes-prev.ioBytes) / elapsed
+			}
+		}
+	}
+
+	return procStat{
+		pid:     pid,
+		comm:    comm,
+		cpuPct:  cpuPct,
+		rssMiB:  float64(rssKB) / 1024,
+		threads: threads,
+		ioRate:  ioRate,
+	}, true
+}
+
+// inContainer guesses whether the process runs inside a container by checking
+// for a container marker file or a non-root cgroup path for PID 1.
+func inContainer() bool {
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return true
+	}
+	data, err := ioutil.ReadFile("/proc/1/cgroup")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) == 3 && fields[2] != "/" && fields[2] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func cgroupVersion() string {
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err == nil {
+		return "v2"
+	}
+	if _, err := os.Stat("/sys/fs/cgroup/memory/memory.limit_in_bytes"); err == nil {
+		return "v1"
+	}
+	return ""
+}
+
+func cgroupMemStats() (current, limit uint64, ok bool) {
+	switch cgroupVer {
+	case "v2":
+		c := read("/sys/fs/cgroup/memory.current")
+		m := read("/sys/fs/cgroup/memory.max")
+		if c == "" || m == "" || m == "max" {
+			return 0, 0, false
+		}
+		current, _ = strconv.ParseUint(c, 10, 64)
+		limit, _ = strconv.ParseUint(m, 10, 64)
+		return current, limit, true
+	case "v1":
+		c := read("/sys/fs/cgroup/memory/memory.usage_in_bytes")
+		m := read("/sys/fs/cgroup/memory/memory.limit_in_bytes")
+		if c == "" || m == "" {
+			return 0, 0, false
+		}
+		current, _ = strconv.ParseUint(c, 10, 64)
+		limit, _ = strconv.ParseUint(m, 10, 64)
+		const unlimited = 1 << 62 // with no limit set, memory.limit_in_bytes reports a value near 2^63, above this
+		if limit > unlimited {
+			return 0, 0, false
+		}
+		return current, limit, true
+	}
+	return 0, 0, false
+}
+
+func cgroupCPUQuota() float64 {
+	switch cgroupVer {
+	case "v2":
+		fields := strings.Fields(read("/sys/fs/cgroup/cpu.max"))
+		if len(fields) != 2 || fields[0] == "max" {
+			return float64(runtime.NumCPU())
+		}
+		quota, err1 := strconv.ParseFloat(fields[0], 64)
+		period, err2 := strconv.ParseFloat(fields[1], 64)
+		if err1 != nil || err2 != nil || period == 0 {
+			return float64(runtime.NumCPU())
+		}
+		return quota / period
+	case "v1":
+		quota, err1 := strconv.ParseFloat(read("/sys/fs/cgroup/cpu/cpu.cfs_quota_us"), 64)
+		period, err2 := strconv.ParseFloat(read("/sys/fs/cgroup/cpu/cpu.cfs_period_us"), 64)
+		if err1 != nil || err2 != nil || quota <= 0 || period == 0 {
+			return float64(runtime.NumCPU())
+		}
+		return quota / period
+	}
+	return float64(runtime.NumCPU())
+}
+
+// cgroupCPUUsageUsec returns the cumulative CPU time consumed by the cgroup,
+// in microseconds.
+func cgroupCPUUsageUsec() (uint64, bool) {
+	switch cgroupVer {
+	case "v2":
+		data, err := ioutil.ReadFile("/sys/fs/cgroup/cpu.stat")
+		if err != nil {
+			return 0, false
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(line, "usage_usec") {
+				f := strings.Fields(line)
+				if len(f) != 2 {
+					return 0, false
+				}
+				v, err := strconv.ParseUint(f[1], 10, 64)
+				return v, err == nil
+			}
+		}
+		return 0, false
+	case "v1":
+		ns, err := strconv.ParseUint(read("/sys/fs/cgroup/cpuacct/cpuacct.usage"), 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return ns / 1000, true
+	}
+	return 0, false
+}
+
+func cgroupStatus() string {
+	quota := cgroupCPUQuota()
+	usage, ok := cgroupCPUUsageUsec()
+	now := time.Now()
+
+	var cpuPct float64
+	if ok {
+		cgMu.Lock()
+		prevUsage, prevTime := cgPrevUsage, cgPrevTime
+		cgPrevUsage, cgPrevTime = usage, now
+		cgMu.Unlock()
+		if !prevTime.IsZero() {
+			if elapsed := now.Sub(prevTime).Seconds(); elapsed > 0 && quota > 0 {
+				cpuPct = float64(usage-prevUsage) / 1e6 / (elapsed * quota) * 100
+			}
+		}
+	}
+
+	mem := "n/a"
+	if _, limit, ok := cgroupMemStats(); ok {
+		mem = humanBytes(limit)
+	}
+
+	return fmt.Sprintf("cgroup mode: %s, cpus=%.1f (%.1f%%), mem=%s", cgroupVer, quota, cpuPct, mem)
+}
+
 func calcMinInt(a, b int) int {
 	if b < a {
 		return b
@@ -588,3 +1517,112 @@ func humanBytes(val uint64) string {
 	}
 	return fmt.Sprintf("%dB", val)
 }
+
+// Snapshot holds the CPU, memory, load, disk and sensor data the TUI renders,
+// decoupled from terminal formatting so it can be exported as Prometheus
+// metrics or JSON. Network, process and battery data are not included.
+type Snapshot struct {
+	Cores []CoreFreq `json:"cores"`
+	Mem   MemStats   `json:"mem"`
+	Load  [3]float64 `json:"load"`
+	Disk  DiskStats  `json:"disk"`
+	Temps []Temp     `json:"-"`
+	Fans  []Fan      `json:"-"`
+}
+
+func takeSnapshot() Snapshot {
+	mem, _ := memSnapshot()
+	ls, _ := loadSnapshot()
+	temps, fans, _ := sensorsSnapshot()
+	return Snapshot{
+		Cores: cpuSnapshot(),
+		Mem:   mem,
+		Load:  [3]float64{ls.load1, ls.load5, ls.load15},
+		Disk:  diskSnapshot(),
+		Temps: temps,
+		Fans:  fans,
+	}
+}
+
+func renderPrometheus(s Snapshot) string {
+	var b strings.Builder
+	for _, c := range s.Cores {
+		fmt.Fprintf(&b, "monitor_cpu_freq_mhz{core=\"%d\"} %g\n", c.ID, c.MHz)
+		fmt.Fprintf(&b, "monitor_cpu_freq_mhz_min{core=\"%d\"} %g\n", c.ID, c.Min)
+		fmt.Fprintf(&b, "monitor_cpu_freq_mhz_max{core=\"%d\"} %g\n", c.ID, c.Max)
+	}
+	fmt.Fprintf(&b, "monitor_mem_total_bytes %d\n", s.Mem.TotalKiB*1024)
+	fmt.Fprintf(&b, "monitor_mem_free_bytes %d\n", s.Mem.FreeKiB*1024)
+	fmt.Fprintf(&b, "monitor_mem_available_bytes %d\n", s.Mem.AvailableKiB*1024)
+	fmt.Fprintf(&b, "monitor_mem_swap_used_bytes %d\n", s.Mem.SwapUsedKiB*1024)
+
+	periods := [3]string{"1m", "5m", "15m"}
+	for i, v := range s.Load {
+		fmt.Fprintf(&b, "monitor_loadavg{period=%q} %g\n", periods[i], v)
+	}
+
+	fmt.Fprintf(&b, "monitor_root_disk_size_bytes{device=%q} %d\n", s.Disk.Device, s.Disk.Size)
+	fmt.Fprintf(&b, "monitor_root_disk_used_bytes{device=%q} %d\n", s.Disk.Device, s.Disk.Used)
+	fmt.Fprintf(&b, "monitor_root_disk_free_bytes{device=%q} %d\n", s.Disk.Device, s.Disk.Free)
+
+	for _, t := range s.Temps {
+		c, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, "monitor_temp_celsius{module=%q,label=%q} %g\n", t.module, t.label, c/1000)
+	}
+	for _, f := range s.Fans {
+		rpm, err := strconv.ParseFloat(f.value, 64)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, "monitor_fan_rpm{label=%q} %g\n", f.label, rpm)
+	}
+	return b.String()
+}
+
+func renderJSON(s Snapshot) (string, error) {
+	type tempOut struct {
+		Module  string  `json:"module"`
+		Label   string  `json:"label"`
+		Celsius float64 `json:"celsius"`
+	}
+	type fanOut struct {
+		Label string  `json:"label"`
+		RPM   float64 `json:"rpm"`
+	}
+	out := struct {
+		Snapshot
+		Temps []tempOut `json:"temps"`
+		Fans  []fanOut  `json:"fans"`
+	}{Snapshot: s}
+
+	for _, t := range s.Temps {
+		c, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			continue
+		}
+		out.Temps = append(out.Temps, tempOut{Module: t.module, Label: t.label, Celsius: c / 1000})
+	}
+	for _, f := range s.Fans {
+		rpm, err := strconv.ParseFloat(f.value, 64)
+		if err != nil {
+			continue
+		}
+		out.Fans = append(out.Fans, fanOut{Label: f.label, RPM: rpm})
+	}
+
+	b, err := json.Marshal(out)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func serveMetrics(addr string) {
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, renderPrometheus(takeSnapshot()))
+	})
+	log.Fatal(http.ListenAndServe(addr, nil))
+}